@@ -0,0 +1,234 @@
+// Copyright 2017 The Celo Authors
+// This file is part of the celo library.
+//
+// The celo library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The celo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the celo library. If not, see <http://www.gnu.org/licenses/>.
+
+package enodes
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// ErrNotFound is returned by KVStore.Get when the requested key does not exist.
+var ErrNotFound = errors.New("enodes: key not found")
+
+// KVStore is the minimal batched key/value store that SignedAnnounceVersionDB
+// is built on, mirroring go-ethereum's ethdb interfaces. It lets embedders
+// back the announce table with a leveldb instance (the default), an
+// in-memory store (used in tests), or a handle reused from an existing chain
+// database, without SignedAnnounceVersionDB needing to know which. A pebble
+// or badger backend can be added the same way the leveldb one below is,
+// simply by implementing this interface.
+type KVStore interface {
+	Get(key []byte) ([]byte, error)
+	Put(key, value []byte) error
+	Delete(key []byte) error
+	NewBatch() KVBatch
+	NewIteratorWithPrefix(prefix []byte) KVIterator
+	Close() error
+}
+
+// KVBatch buffers a set of writes to be applied atomically.
+type KVBatch interface {
+	Put(key, value []byte)
+	Delete(key []byte)
+	Len() int
+	Write() error
+}
+
+// KVIterator walks the keys of a KVStore matching a given prefix.
+type KVIterator interface {
+	Next() bool
+	Key() []byte
+	Value() []byte
+	Error() error
+	Release()
+}
+
+// leveldbStore adapts a *leveldb.DB to KVStore. This is the backend used by
+// OpenSignedAnnounceVersionDB.
+type leveldbStore struct {
+	db           *leveldb.DB
+	writeOptions *opt.WriteOptions
+}
+
+func newLeveldbStore(db *leveldb.DB) KVStore {
+	return &leveldbStore{db: db, writeOptions: &opt.WriteOptions{NoWriteMerge: true}}
+}
+
+func (s *leveldbStore) Get(key []byte) ([]byte, error) {
+	val, err := s.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	return val, err
+}
+
+func (s *leveldbStore) Put(key, value []byte) error { return s.db.Put(key, value, s.writeOptions) }
+func (s *leveldbStore) Delete(key []byte) error     { return s.db.Delete(key, s.writeOptions) }
+func (s *leveldbStore) Close() error                { return s.db.Close() }
+
+func (s *leveldbStore) NewBatch() KVBatch {
+	return &leveldbBatch{db: s.db, batch: new(leveldb.Batch), writeOptions: s.writeOptions}
+}
+
+func (s *leveldbStore) NewIteratorWithPrefix(prefix []byte) KVIterator {
+	return &leveldbIterator{iter: s.db.NewIterator(util.BytesPrefix(prefix), nil)}
+}
+
+type leveldbBatch struct {
+	db           *leveldb.DB
+	batch        *leveldb.Batch
+	writeOptions *opt.WriteOptions
+}
+
+func (b *leveldbBatch) Put(key, value []byte) { b.batch.Put(key, value) }
+func (b *leveldbBatch) Delete(key []byte)     { b.batch.Delete(key) }
+func (b *leveldbBatch) Len() int              { return b.batch.Len() }
+func (b *leveldbBatch) Write() error          { return b.db.Write(b.batch, b.writeOptions) }
+
+type leveldbIterator struct {
+	iter iterator.Iterator
+}
+
+func (i *leveldbIterator) Next() bool    { return i.iter.Next() }
+func (i *leveldbIterator) Key() []byte   { return i.iter.Key() }
+func (i *leveldbIterator) Value() []byte { return i.iter.Value() }
+func (i *leveldbIterator) Error() error  { return i.iter.Error() }
+func (i *leveldbIterator) Release()      { i.iter.Release() }
+
+// memoryStore is a plain in-memory KVStore used in tests, replacing the
+// previous approach of pointing leveldb itself at an in-memory storage.Storage.
+type memoryStore struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+// newMemoryStore constructs an empty, unbounded in-memory KVStore.
+func newMemoryStore() KVStore {
+	return &memoryStore{data: make(map[string][]byte)}
+}
+
+func (m *memoryStore) Get(key []byte) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	val, ok := m.data[string(key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := make([]byte, len(val))
+	copy(cp, val)
+	return cp, nil
+}
+
+func (m *memoryStore) Put(key, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := make([]byte, len(value))
+	copy(cp, value)
+	m.data[string(key)] = cp
+	return nil
+}
+
+func (m *memoryStore) Delete(key []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data, string(key))
+	return nil
+}
+
+func (m *memoryStore) Close() error { return nil }
+
+func (m *memoryStore) NewBatch() KVBatch {
+	return &memoryBatch{store: m}
+}
+
+func (m *memoryStore) NewIteratorWithPrefix(prefix []byte) KVIterator {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var keys []string
+	for k := range m.data {
+		if strings.HasPrefix(k, string(prefix)) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return &memoryIterator{store: m, keys: keys, pos: -1}
+}
+
+type memoryOp struct {
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+type memoryBatch struct {
+	store *memoryStore
+	ops   []memoryOp
+}
+
+func (b *memoryBatch) Put(key, value []byte) {
+	b.ops = append(b.ops, memoryOp{key: key, value: value})
+}
+
+func (b *memoryBatch) Delete(key []byte) {
+	b.ops = append(b.ops, memoryOp{key: key, delete: true})
+}
+
+func (b *memoryBatch) Len() int { return len(b.ops) }
+
+func (b *memoryBatch) Write() error {
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+	for _, op := range b.ops {
+		if op.delete {
+			delete(b.store.data, string(op.key))
+			continue
+		}
+		cp := make([]byte, len(op.value))
+		copy(cp, op.value)
+		b.store.data[string(op.key)] = cp
+	}
+	return nil
+}
+
+type memoryIterator struct {
+	store *memoryStore
+	keys  []string
+	pos   int
+}
+
+func (it *memoryIterator) Next() bool {
+	it.pos++
+	return it.pos < len(it.keys)
+}
+
+func (it *memoryIterator) Key() []byte { return []byte(it.keys[it.pos]) }
+
+func (it *memoryIterator) Value() []byte {
+	it.store.mu.RLock()
+	defer it.store.mu.RUnlock()
+	return it.store.data[it.keys[it.pos]]
+}
+
+func (it *memoryIterator) Error() error { return nil }
+func (it *memoryIterator) Release()     {}
@@ -0,0 +1,115 @@
+// Copyright 2017 The Celo Authors
+// This file is part of the celo library.
+//
+// The celo library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The celo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the celo library. If not, see <http://www.gnu.org/licenses/>.
+
+package enodes
+
+import "testing"
+
+func TestMemoryStoreGetPutDelete(t *testing.T) {
+	kv := newMemoryStore()
+
+	if _, err := kv.Get([]byte("a")); err != ErrNotFound {
+		t.Fatalf("Get() on missing key error = %v, want ErrNotFound", err)
+	}
+
+	if err := kv.Put([]byte("a"), []byte("1")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	val, err := kv.Get([]byte("a"))
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if string(val) != "1" {
+		t.Errorf("Get() = %q, want %q", val, "1")
+	}
+
+	if err := kv.Delete([]byte("a")); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := kv.Get([]byte("a")); err != ErrNotFound {
+		t.Errorf("Get() after Delete() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryStorePutStoresACopy(t *testing.T) {
+	kv := newMemoryStore()
+	value := []byte("1")
+	if err := kv.Put([]byte("a"), value); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	value[0] = 'x'
+
+	got, err := kv.Get([]byte("a"))
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if string(got) != "1" {
+		t.Errorf("Get() = %q, want %q (mutating the caller's slice after Put must not affect the store)", got, "1")
+	}
+}
+
+func TestMemoryStoreBatch(t *testing.T) {
+	kv := newMemoryStore()
+	if err := kv.Put([]byte("address:a"), []byte("old")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	batch := kv.NewBatch()
+	batch.Put([]byte("address:b"), []byte("new"))
+	batch.Delete([]byte("address:a"))
+	if got, want := batch.Len(), 2; got != want {
+		t.Errorf("batch.Len() = %d, want %d", got, want)
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("batch.Write() error = %v", err)
+	}
+
+	if _, err := kv.Get([]byte("address:a")); err != ErrNotFound {
+		t.Errorf("Get(a) after batch delete error = %v, want ErrNotFound", err)
+	}
+	if val, err := kv.Get([]byte("address:b")); err != nil || string(val) != "new" {
+		t.Errorf("Get(b) = %q, %v, want %q, nil", val, err, "new")
+	}
+}
+
+func TestMemoryStoreIteratorWithPrefix(t *testing.T) {
+	kv := newMemoryStore()
+	for _, kvPair := range [][2]string{
+		{"address:b", "2"},
+		{"address:a", "1"},
+		{"other:c", "3"},
+	} {
+		if err := kv.Put([]byte(kvPair[0]), []byte(kvPair[1])); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+
+	iter := kv.NewIteratorWithPrefix([]byte("address:"))
+	defer iter.Release()
+
+	var keys []string
+	for iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+	if err := iter.Error(); err != nil {
+		t.Fatalf("iter.Error() = %v", err)
+	}
+
+	want := []string{"address:a", "address:b"}
+	if len(keys) != len(want) || keys[0] != want[0] || keys[1] != want[1] {
+		t.Errorf("iterated keys = %v, want %v (sorted, prefix-filtered)", keys, want)
+	}
+}
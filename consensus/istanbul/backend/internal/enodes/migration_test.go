@@ -0,0 +1,63 @@
+// Copyright 2017 The Celo Authors
+// This file is part of the celo library.
+//
+// The celo library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The celo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the celo library. If not, see <http://www.gnu.org/licenses/>.
+
+package enodes
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+func TestCheckAndRunMigrationsRefusesNewerVersion(t *testing.T) {
+	kv := newMemoryStore()
+	if err := writeDBVersion(kv, dbVersionSignedAnnounceVersion+1); err != nil {
+		t.Fatalf("writeDBVersion() error = %v", err)
+	}
+
+	if err := checkAndRunMigrations(kv, log.New("test", "t")); err == nil {
+		t.Fatal("checkAndRunMigrations() error = nil, want error for a newer on-disk schema version")
+	}
+}
+
+func TestOpenSignedAnnounceVersionDBFallsBackOnBadSchema(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "signed-announce-version-db")
+
+	kv, err := newBackend(path, log.New("test", "t"))
+	if err != nil {
+		t.Fatalf("newBackend() error = %v", err)
+	}
+	if err := writeDBVersion(kv, dbVersionSignedAnnounceVersion+1); err != nil {
+		t.Fatalf("writeDBVersion() error = %v", err)
+	}
+	if err := kv.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	svdb, err := OpenSignedAnnounceVersionDB(path, Options{})
+	if err != nil {
+		t.Fatalf("OpenSignedAnnounceVersionDB() error = %v, want it to fall back to a fresh db", err)
+	}
+	defer svdb.Close()
+
+	if _, err := svdb.getEntry(common.Address{}); err != nil && !errors.Is(err, ErrNotFound) {
+		t.Errorf("getEntry() on a fresh fallback db error = %v, want ErrNotFound", err)
+	}
+}
@@ -20,121 +20,466 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/syndtr/goleveldb/leveldb/opt"
-	"github.com/syndtr/goleveldb/leveldb/util"
-
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/istanbul"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
-// // Keys in the node database.
-// const (
-// 	dbVersionKey    = "version"  // Version of the database to flush if changes
-// 	dbAddressPrefix = "address:" // Identifier to prefix address keys with
-// )
+// Keys in the node database.
+const (
+	dbVersionKey    = "version"  // Version of the database to flush if changes
+	dbAddressPrefix = "address:" // Identifier to prefix address keys with
+)
 
 const (
-	// dbNodeExpiration = 24 * time.Hour // Time after which an unseen node should be dropped.
-	// dbCleanupCycle   = time.Hour      // Time period for running the expiration task.
+	dbNodeExpiration = 24 * time.Hour // Time after which an unseen entry should be dropped.
+	dbCleanupCycle   = time.Hour      // Time period for running the expiration task.
+
 	dbVersionSignedAnnounceVersion = 0
 )
 
+// migrationFunc upgrades the on-disk schema of a signed announce version db.
+type migrationFunc func(KVStore) error
+
+type migration struct {
+	from, to uint
+	fn       migrationFunc
+}
+
+var migrations []migration
+
+// RegisterMigration registers a function that upgrades the on-disk schema
+// from version from to version to. When an existing database reports a
+// stored version older than dbVersionSignedAnnounceVersion, registered
+// migrations are applied in sequence until the schema is current, so that
+// changes to the RLP layout of stored entries (such as adding ENR support)
+// can ship without wiping validators' persisted announce state.
+func RegisterMigration(from, to uint, fn func(KVStore) error) {
+	migrations = append(migrations, migration{from, to, fn})
+}
+
+// Clock abstracts away time.Now so tests can drive expiration deterministically.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// Options configures a SignedAnnounceVersionDB. The zero value is valid;
+// unset fields fall back to their defaults.
+type Options struct {
+	// Expiration is how old an entry's Timestamp may get before the
+	// background janitor removes it. Defaults to dbNodeExpiration.
+	Expiration time.Duration
+	// CleanupCycle is how often the janitor wakes up to look for expired
+	// entries. Defaults to dbCleanupCycle.
+	CleanupCycle time.Duration
+	// Clock supplies the current time. Defaults to the system clock;
+	// overridable in tests.
+	Clock Clock
+}
+
+func (o *Options) setDefaults() {
+	if o.Expiration == 0 {
+		o.Expiration = dbNodeExpiration
+	}
+	if o.CleanupCycle == 0 {
+		o.CleanupCycle = dbCleanupCycle
+	}
+	if o.Clock == nil {
+		o.Clock = systemClock{}
+	}
+}
+
 // SignedAnnounceVersionDB represents a Map that can be accessed either
 // by address or enode
 type SignedAnnounceVersionDB struct {
-	db           *leveldb.DB //the actual DB
-	logger       log.Logger
-	writeOptions *opt.WriteOptions
+	db      KVStore // the underlying store
+	logger  log.Logger
+	options Options
+	quit    chan struct{}
+	wg      sync.WaitGroup
+
+	feed  event.Feed
+	scope event.SubscriptionScope
 }
 
-// SignedAnnounceVersionEntry is an entry
-type SignedAnnounceVersion struct {
+// AnnounceVersionChangeKind describes how an entry in the signed announce
+// version table changed.
+type AnnounceVersionChangeKind int
+
+const (
+	AnnounceVersionAdded AnnounceVersionChangeKind = iota
+	AnnounceVersionUpdated
+	AnnounceVersionRemoved
+)
+
+func (k AnnounceVersionChangeKind) String() string {
+	switch k {
+	case AnnounceVersionAdded:
+		return "Added"
+	case AnnounceVersionUpdated:
+		return "Updated"
+	case AnnounceVersionRemoved:
+		return "Removed"
+	default:
+		return "Unknown"
+	}
+}
+
+// AnnounceVersionEvent is sent on the feed returned by SubscribeAnnounceVersion
+// or SubscribeAnnounceVersionBatch whenever an entry in the table is added,
+// updated, or removed.
+type AnnounceVersionEvent struct {
+	Address common.Address
+	OldSeq  uint64
+	NewSeq  uint64
+	Kind    AnnounceVersionChangeKind
+}
+
+// SubscribeAnnounceVersion registers a subscription that delivers one
+// AnnounceVersionEvent at a time. This lets the announce protocol and the
+// proxy/proxied-validator subsystem react to validator set churn without
+// polling GetAllEntries. Callers that expect large announce broadcasts and
+// want to avoid one wakeup per address should use
+// SubscribeAnnounceVersionBatch instead.
+func (svdb *SignedAnnounceVersionDB) SubscribeAnnounceVersion(ch chan<- AnnounceVersionEvent) event.Subscription {
+	batchCh := make(chan []AnnounceVersionEvent)
+	sub := svdb.scope.Track(svdb.feed.Subscribe(batchCh))
+	go func() {
+		for {
+			select {
+			case events := <-batchCh:
+				for _, ev := range events {
+					select {
+					case ch <- ev:
+					case <-sub.Err():
+						return
+					}
+				}
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	return sub
+}
+
+// SubscribeAnnounceVersionBatch registers a subscription for
+// AnnounceVersionEvent notifications. All the events produced by a single
+// Upsert, RemoveEntry, or PruneEntries call are coalesced into one
+// []AnnounceVersionEvent notification, so that large announce broadcasts
+// don't thrash subscribers with one event per address.
+func (svdb *SignedAnnounceVersionDB) SubscribeAnnounceVersionBatch(ch chan<- []AnnounceVersionEvent) event.Subscription {
+	return svdb.scope.Track(svdb.feed.Subscribe(ch))
+}
+
+// SignedAnnounceRecord is a signed Istanbul announce message that carries a
+// full Ethereum Node Record (ENR, EIP-778) rather than a bare version
+// integer. Record carries the validator's connection info (IP, TCP/UDP
+// ports, public key, and any additional k/v pairs) and is itself signed
+// under its own identity scheme; Seq mirrors the record's sequence number
+// and Signature is the Istanbul signature over (Address, Seq, Record) binding
+// the record to a validator address.
+type SignedAnnounceRecord struct {
 	Address   common.Address
-	Version   uint
+	Seq       uint64
+	Record    *enr.Record
 	Signature []byte
 }
 
+// signedAnnounceVersionSchemes is the set of identity schemes accepted when
+// validating the self-signature of a gossiped node record.
+var signedAnnounceVersionSchemes = enr.SchemeMap{"v4": enode.ValidSchemesV4["v4"]}
+
+// ValidateSignature checks the Istanbul signature over (Address, Seq,
+// Record) and the record's own identity-scheme signature. Binding Record
+// into the signed payload ensures a peer cannot graft a different,
+// self-valid node record onto a victim's genuinely signed (Address, Seq).
+func (sr *SignedAnnounceRecord) ValidateSignature() error {
+	bytesNoSignature, err := rlp.EncodeToBytes([]interface{}{sr.Address, sr.Seq, sr.Record})
+	if err != nil {
+		return err
+	}
+	address, err := istanbul.GetSignatureAddress(bytesNoSignature, sr.Signature)
+	if err != nil {
+		return err
+	}
+	if address != sr.Address {
+		return errors.New("Signature does not match address")
+	}
+	if _, err := enode.New(signedAnnounceVersionSchemes, sr.Record); err != nil {
+		return fmt.Errorf("invalid node record: %v", err)
+	}
+	return nil
+}
+
 type SignedAnnounceVersionEntry struct {
-	*SignedAnnounceVersion
+	*SignedAnnounceRecord
 	Timestamp time.Time
 }
 
 // EncodeRLP serializes announceVersion into the Ethereum RLP format.
 func (sve *SignedAnnounceVersionEntry) EncodeRLP(w io.Writer) error {
-	return rlp.Encode(w, []interface{}{sve.Address, sve.Version, sve.Signature, sve.Timestamp})
+	return rlp.Encode(w, []interface{}{sve.Address, sve.Seq, sve.Record, sve.Signature, uint64(sve.Timestamp.UnixNano())})
 }
 
 // DecodeRLP implements rlp.Decoder, and load the announceVerion fields from a RLP stream.
 func (sve *SignedAnnounceVersionEntry) DecodeRLP(s *rlp.Stream) error {
 	var msg struct {
 		Address   common.Address
-		Version   uint
+		Seq       uint64
+		Record    *enr.Record
 		Signature []byte
-		Timestamp time.Time
+		Timestamp uint64
 	}
 
 	if err := s.Decode(&msg); err != nil {
 		return err
 	}
-	sve.Address, sve.Version, sve.Signature, sve.Timestamp = msg.Address, msg.Version, msg.Signature, msg.Timestamp
+	sve.SignedAnnounceRecord = &SignedAnnounceRecord{
+		Address:   msg.Address,
+		Seq:       msg.Seq,
+		Record:    msg.Record,
+		Signature: msg.Signature,
+	}
+	sve.Timestamp = time.Unix(0, int64(msg.Timestamp))
 	return nil
 }
 
 func (sve *SignedAnnounceVersionEntry) String() string {
-	return fmt.Sprintf("{Address: %v, Version: %v, Signature.length: %v, Timestamp: %v}", sve.Address, sve.Version, len(sve.Signature), sve.Timestamp)
+	return fmt.Sprintf("{Address: %v, Seq: %v, Signature.length: %v, Timestamp: %v}", sve.Address, sve.Seq, len(sve.Signature), sve.Timestamp)
+}
+
+// OpenSignedAnnounceVersionDB opens a signed announce version database for storing
+// signedAnnounceVersions. If no path is given an in-memory, temporary database is constructed.
+// opts configures entry expiration; the zero value uses the defaults.
+func OpenSignedAnnounceVersionDB(path string, opts Options) (*SignedAnnounceVersionDB, error) {
+	logger := log.New("db", "SignedAnnounceVersionDB")
+
+	// openBackendWithFallback already brings kv's schema up to date (including
+	// the rename-to-.bak recovery path), so construct directly on top of it
+	// rather than through NewWithBackend, which would run the same migration
+	// check a second time.
+	kv, err := openBackendWithFallback(path, logger)
+	if err != nil {
+		return nil, err
+	}
+	opts.setDefaults()
+	return newSignedAnnounceVersionDB(kv, opts, logger), nil
+}
+
+// NewWithBackend constructs a SignedAnnounceVersionDB directly on top of an
+// arbitrary KVStore, migrating its schema if necessary. This lets embedders
+// reuse an existing chain database handle, or a freezer-style append-only
+// log, instead of opening a dedicated leveldb instance.
+func NewWithBackend(kv KVStore, opts Options) (*SignedAnnounceVersionDB, error) {
+	opts.setDefaults()
+
+	logger := log.New("db", "SignedAnnounceVersionDB")
+	if err := checkAndRunMigrations(kv, logger); err != nil {
+		return nil, err
+	}
+	return newSignedAnnounceVersionDB(kv, opts, logger), nil
 }
 
-func (sv *SignedAnnounceVersion) ValidateSignature() error {
-	signedAnnounceVersionNoSig := &SignedAnnounceVersion{
-		Address: sv.Address,
-		Version: sv.Version,
+// newSignedAnnounceVersionDB assembles a SignedAnnounceVersionDB over an
+// already-migrated kv and starts its background janitor. opts must already
+// have setDefaults applied.
+func newSignedAnnounceVersionDB(kv KVStore, opts Options, logger log.Logger) *SignedAnnounceVersionDB {
+	svdb := &SignedAnnounceVersionDB{
+		db:      kv,
+		logger:  logger,
+		options: opts,
+		quit:    make(chan struct{}),
+	}
+	svdb.wg.Add(1)
+	go svdb.expirer()
+	return svdb
+}
+
+// newBackend opens the default leveldb-backed KVStore, or an in-memory one
+// if path is empty.
+func newBackend(path string, logger log.Logger) (KVStore, error) {
+	if path == "" {
+		return newMemoryStore(), nil
 	}
-	bytesNoSignature, err := rlp.EncodeToBytes(signedAnnounceVersionNoSig)
+	db, err := newPersistentDB(path, logger)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return newLeveldbStore(db), nil
+}
+
+// openBackendWithFallback opens the default backend at path and brings its
+// schema up to dbVersionSignedAnnounceVersion. If migration fails on a
+// persistent database, the old directory is moved aside with a ".bak" suffix
+// and a fresh database is started, matching how geth handles incompatible
+// node databases.
+func openBackendWithFallback(path string, logger log.Logger) (KVStore, error) {
+	kv, err := newBackend(path, logger)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAndRunMigrations(kv, logger); err == nil {
+		return kv, nil
+	} else {
+		kv.Close()
+		if path == "" {
+			return nil, err
+		}
+		logger.Warn("Failed to migrate signed announce version db, starting fresh", "err", err)
+
+		backup := path + ".bak"
+		os.RemoveAll(backup)
+		if err := os.Rename(path, backup); err != nil {
+			return nil, fmt.Errorf("failed to back up incompatible signed announce version db: %v", err)
+		}
+		kv, err = newBackend(path, logger)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkAndRunMigrations(kv, logger); err != nil {
+			kv.Close()
+			return nil, err
+		}
+		return kv, nil
 	}
-	address, err := istanbul.GetSignatureAddress(bytesNoSignature, sv.Signature)
+}
+
+// checkAndRunMigrations reads the stored schema version, refuses to open a
+// database from a newer version of the code, and applies registered
+// migrations to bring an older database up to dbVersionSignedAnnounceVersion.
+func checkAndRunMigrations(kv KVStore, logger log.Logger) error {
+	version, found, err := readDBVersion(kv)
 	if err != nil {
 		return err
 	}
-	if address != sv.Address {
-		return errors.New("Signature does not match address")
+	if !found {
+		return writeDBVersion(kv, dbVersionSignedAnnounceVersion)
+	}
+	if version > dbVersionSignedAnnounceVersion {
+		return fmt.Errorf("signed announce version db: on-disk schema version %d is newer than the supported version %d", version, dbVersionSignedAnnounceVersion)
+	}
+	for version < dbVersionSignedAnnounceVersion {
+		m := findMigration(version)
+		if m == nil {
+			return fmt.Errorf("signed announce version db: no migration registered from schema version %d", version)
+		}
+		logger.Info("Migrating signed announce version db", "from", m.from, "to", m.to)
+		if err := m.fn(kv); err != nil {
+			return fmt.Errorf("migration from version %d to %d failed: %v", m.from, m.to, err)
+		}
+		version = m.to
+	}
+	return writeDBVersion(kv, dbVersionSignedAnnounceVersion)
+}
+
+func findMigration(from uint) *migration {
+	for i := range migrations {
+		if migrations[i].from == from {
+			return &migrations[i]
+		}
 	}
 	return nil
 }
 
-// OpenSignedAnnounceVersionDB opens a signed announce version database for storing
-// signedAnnounceVersions. If no path is given an in-memory, temporary database is constructed.
-func OpenSignedAnnounceVersionDB(path string) (*SignedAnnounceVersionDB, error) {
-	var db *leveldb.DB
-	var err error
+func readDBVersion(kv KVStore) (uint, bool, error) {
+	raw, err := kv.Get([]byte(dbVersionKey))
+	if err == ErrNotFound {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	var version uint
+	if err := rlp.DecodeBytes(raw, &version); err != nil {
+		return 0, false, err
+	}
+	return version, true, nil
+}
 
-	logger := log.New("db", "SignedAnnounceVersionDB")
+func writeDBVersion(kv KVStore, version uint) error {
+	raw, err := rlp.EncodeToBytes(version)
+	if err != nil {
+		return err
+	}
+	return kv.Put([]byte(dbVersionKey), raw)
+}
 
-	if path == "" {
-		db, err = newMemoryDB()
-	} else {
-		db, err = newPersistentDB(path, logger)
+// expirer runs in its own goroutine, periodically pruning entries whose
+// Timestamp has fallen outside the configured Expiration window. This
+// ensures a validator that goes silent (or is partitioned) is eventually
+// dropped instead of being kept alive forever by peers re-gossiping an
+// unchanged entry. The wake cadence itself is driven by a real time.Ticker,
+// not options.Clock: Clock only makes the expiration deathline computed in
+// expireEntries deterministic, so tests should call expireEntries directly
+// rather than relying on the janitor's timing.
+func (svdb *SignedAnnounceVersionDB) expirer() {
+	defer svdb.wg.Done()
+
+	tick := time.NewTicker(svdb.options.CleanupCycle)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			if err := svdb.expireEntries(); err != nil {
+				svdb.logger.Error("Failed to expire signed announce versions", "err", err)
+			}
+		case <-svdb.quit:
+			return
+		}
 	}
+}
+
+// expireEntries removes entries whose Timestamp is older than Expiration.
+func (svdb *SignedAnnounceVersionDB) expireEntries() error {
+	deathline := svdb.options.Clock.Now().Add(-svdb.options.Expiration)
 
+	batch := svdb.db.NewBatch()
+	var events []AnnounceVersionEvent
+	err := svdb.iterateOverAddressEntries(func(address common.Address, entry *SignedAnnounceVersionEntry) error {
+		if entry.Timestamp.Before(deathline) {
+			batch.Delete(addressKey(address))
+			events = append(events, AnnounceVersionEvent{
+				Address: address,
+				OldSeq:  entry.Seq,
+				Kind:    AnnounceVersionRemoved,
+			})
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return &SignedAnnounceVersionDB{
-		db:      db,
-		logger:  logger,
-		writeOptions: &opt.WriteOptions{NoWriteMerge: true},
-	}, nil
+	if batch.Len() == 0 {
+		return nil
+	}
+	svdb.logger.Trace("Expiring signed announce versions", "count", batch.Len())
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	svdb.feed.Send(events)
+	return nil
 }
 
-// Close flushes and closes the database files.
+// Close stops the background janitor, closes all active subscriptions, and
+// flushes and closes the database files.
 func (svdb *SignedAnnounceVersionDB) Close() error {
+	close(svdb.quit)
+	svdb.wg.Wait()
+	svdb.scope.Close()
 	return svdb.db.Close()
 }
 
@@ -154,56 +499,85 @@ func (svdb *SignedAnnounceVersionDB) String() string {
 	return b.String()
 }
 
-// GetVersionFromAddress will return the version for an address if it's known
-func (svdb *SignedAnnounceVersionDB) GetVersionFromAddress(address common.Address) (uint, error) {
+// GetVersionFromAddress will return the record's sequence number for an
+// address if it's known. Seq is the ENR-aligned counterpart of the old
+// integer version field.
+func (svdb *SignedAnnounceVersionDB) GetVersionFromAddress(address common.Address) (uint64, error) {
 	entry, err := svdb.getEntry(address)
 	if err != nil {
 		return 0, err
 	}
-	return entry.Version, nil
+	return entry.Seq, nil
 }
 
-// Upsert inserts any new entries or entries with a Version higher than the
-// existing version. Returns if there were any new or updated entries
-func (svdb *SignedAnnounceVersionDB) Upsert(signedAnnounceVersions []*SignedAnnounceVersion) (bool, error) {
-    logger := svdb.logger.New("func", "Upsert")
-	batch := new(leveldb.Batch)
+// GetRecordFromAddress returns the full node record gossiped by address,
+// along with its sequence number, so that the announce protocol can learn a
+// validator's connection info without a separate enode lookup.
+func (svdb *SignedAnnounceVersionDB) GetRecordFromAddress(address common.Address) (*enr.Record, uint64, error) {
+	entry, err := svdb.getEntry(address)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entry.Record, entry.Seq, nil
+}
+
+// Upsert inserts any new entries or entries with a Seq higher than the
+// existing entry's Seq. Returns if there were any new or updated entries
+func (svdb *SignedAnnounceVersionDB) Upsert(signedAnnounceRecords []*SignedAnnounceRecord) (bool, error) {
+	logger := svdb.logger.New("func", "Upsert")
+	batch := svdb.db.NewBatch()
 
 	newEntries := false
+	var events []AnnounceVersionEvent
 
-    for _, signedAnnVersion := range signedAnnounceVersions {
-        currentEntry, err := svdb.getEntry(signedAnnVersion.Address)
-        isNew := err == leveldb.ErrNotFound
+	for _, signedAnnRecord := range signedAnnounceRecords {
+		currentEntry, err := svdb.getEntry(signedAnnRecord.Address)
+		isNew := err == ErrNotFound
 		if !isNew && err != nil {
 			return false, err
 		}
-        if !isNew && signedAnnVersion.Version <= currentEntry.Version {
-            logger.Trace("Not inserting, version is not greater than the existing entry",
-                "address", signedAnnVersion.Address, "existing version", currentEntry.Version,
-                "new entry version", signedAnnVersion.Version)
-            continue
-        }
+		if !isNew && signedAnnRecord.Seq <= currentEntry.Seq {
+			logger.Trace("Not inserting, seq is not greater than the existing entry",
+				"address", signedAnnRecord.Address, "existing seq", currentEntry.Seq,
+				"new entry seq", signedAnnRecord.Seq)
+			continue
+		}
 		entry := SignedAnnounceVersionEntry{
-			SignedAnnounceVersion: signedAnnVersion,
-			Timestamp: time.Now(),
+			SignedAnnounceRecord: signedAnnRecord,
+			Timestamp:            svdb.options.Clock.Now(),
 		}
-        entryBytes, err := rlp.EncodeToBytes(entry)
-        if err != nil {
-            return false, err
-        }
-        batch.Put(addressKey(signedAnnVersion.Address), entryBytes)
+		entryBytes, err := rlp.EncodeToBytes(entry)
+		if err != nil {
+			return false, err
+		}
+		batch.Put(addressKey(signedAnnRecord.Address), entryBytes)
 		newEntries = true
-        logger.Trace("Updating with new entry", "isNew", isNew,
-            "address", signedAnnVersion.Address, "new version", signedAnnVersion.Version)
-    }
+		kind := AnnounceVersionAdded
+		var oldSeq uint64
+		if !isNew {
+			kind = AnnounceVersionUpdated
+			oldSeq = currentEntry.Seq
+		}
+		events = append(events, AnnounceVersionEvent{
+			Address: signedAnnRecord.Address,
+			OldSeq:  oldSeq,
+			NewSeq:  signedAnnRecord.Seq,
+			Kind:    kind,
+		})
+		logger.Trace("Updating with new entry", "isNew", isNew,
+			"address", signedAnnRecord.Address, "new seq", signedAnnRecord.Seq)
+	}
 
-    if batch.Len() > 0 {
-        err := svdb.db.Write(batch, svdb.writeOptions)
-        if err != nil {
-            return false, err
-        }
-    }
-    return newEntries, nil
+	if batch.Len() > 0 {
+		err := batch.Write()
+		if err != nil {
+			return false, err
+		}
+		if len(events) > 0 {
+			svdb.feed.Send(events)
+		}
+	}
+	return newEntries, nil
 }
 
 // GetAllEntries gets all entries in the db
@@ -216,42 +590,69 @@ func (svdb *SignedAnnounceVersionDB) GetAllEntries() ([]*SignedAnnounceVersionEn
 	return entries, err
 }
 
-// GetAllSignedAnnounceVersions gets all SignedAnnounceVersions in the db
-func (svdb *SignedAnnounceVersionDB) GetAllSignedAnnounceVersions() ([]*SignedAnnounceVersion, error) {
-	var signedAnnounceVersions []*SignedAnnounceVersion
+// GetAllSignedAnnounceVersions gets all SignedAnnounceRecords in the db
+func (svdb *SignedAnnounceVersionDB) GetAllSignedAnnounceVersions() ([]*SignedAnnounceRecord, error) {
+	var signedAnnounceRecords []*SignedAnnounceRecord
 	err := svdb.iterateOverAddressEntries(func(address common.Address, entry *SignedAnnounceVersionEntry) error {
-		signedAnnounceVersions = append(signedAnnounceVersions, entry.SignedAnnounceVersion)
+		signedAnnounceRecords = append(signedAnnounceRecords, entry.SignedAnnounceRecord)
 		return nil
 	})
-	return signedAnnounceVersions, err
+	return signedAnnounceRecords, err
 }
 
 // RemoveEntry will remove an entry from the table
 func (svdb *SignedAnnounceVersionDB) RemoveEntry(address common.Address) error {
-	batch := new(leveldb.Batch)
+	entry, err := svdb.getEntry(address)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+
+	batch := svdb.db.NewBatch()
 	batch.Delete(addressKey(address))
-	return svdb.db.Write(batch, svdb.writeOptions)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	if entry != nil {
+		svdb.feed.Send([]AnnounceVersionEvent{{
+			Address: address,
+			OldSeq:  entry.Seq,
+			Kind:    AnnounceVersionRemoved,
+		}})
+	}
+	return nil
 }
 
 // PruneEntries will remove entries for all address not present in addressesToKeep
 func (svdb *SignedAnnounceVersionDB) PruneEntries(addressesToKeep map[common.Address]bool) error {
-	batch := new(leveldb.Batch)
+	batch := svdb.db.NewBatch()
+	var events []AnnounceVersionEvent
 	err := svdb.iterateOverAddressEntries(func(address common.Address, entry *SignedAnnounceVersionEntry) error {
 		if !addressesToKeep[address] {
 			svdb.logger.Trace("Deleting entry", "address", address)
 			batch.Delete(addressKey(address))
+			events = append(events, AnnounceVersionEvent{
+				Address: address,
+				OldSeq:  entry.Seq,
+				Kind:    AnnounceVersionRemoved,
+			})
 		}
 		return nil
 	})
 	if err != nil {
 		return err
 	}
-	return svdb.db.Write(batch, svdb.writeOptions)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	if len(events) > 0 {
+		svdb.feed.Send(events)
+	}
+	return nil
 }
 
 func (svdb *SignedAnnounceVersionDB) getEntry(address common.Address) (*SignedAnnounceVersionEntry, error) {
 	var entry SignedAnnounceVersionEntry
-	rawEntry, err := svdb.db.Get(addressKey(address), nil)
+	rawEntry, err := svdb.db.Get(addressKey(address))
 	if err != nil {
 		return nil, err
 	}
@@ -263,13 +664,15 @@ func (svdb *SignedAnnounceVersionDB) getEntry(address common.Address) (*SignedAn
 }
 
 func (svdb *SignedAnnounceVersionDB) iterateOverAddressEntries(onEntry func(common.Address, *SignedAnnounceVersionEntry) error) error {
-	iter := svdb.db.NewIterator(util.BytesPrefix([]byte(dbAddressPrefix)), nil)
+	iter := svdb.db.NewIteratorWithPrefix([]byte(dbAddressPrefix))
 	defer iter.Release()
 
 	for iter.Next() {
 		var entry SignedAnnounceVersionEntry
 		address := common.BytesToAddress(iter.Key()[len(dbAddressPrefix):])
-		rlp.DecodeBytes(iter.Value(), &entry)
+		if err := rlp.DecodeBytes(iter.Value(), &entry); err != nil {
+			return fmt.Errorf("signed announce version db: failed to decode entry for %v: %v", address, err)
+		}
 
 		err := onEntry(address, &entry)
 		if err != nil {
@@ -282,7 +685,7 @@ func (svdb *SignedAnnounceVersionDB) iterateOverAddressEntries(onEntry func(comm
 // SignedAnnounceVersionEntryInfo todo comment
 type SignedAnnounceVersionEntryInfo struct {
 	Address string `json:"address"`
-	Version uint   `json:"version"`
+	Seq     uint64 `json:"seq"`
 }
 
 // Info todo comment
@@ -291,7 +694,7 @@ func (svdb *SignedAnnounceVersionDB) Info() (map[string]*SignedAnnounceVersionEn
 	err := svdb.iterateOverAddressEntries(func(address common.Address, entry *SignedAnnounceVersionEntry) error {
 		dbInfo[address.Hex()] = &SignedAnnounceVersionEntryInfo{
 			Address: entry.Address.Hex(),
-			Version: entry.Version,
+			Seq:     entry.Seq,
 		}
 		return nil
 	})
@@ -0,0 +1,298 @@
+// Copyright 2017 The Celo Authors
+// This file is part of the celo library.
+//
+// The celo library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The celo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the celo library. If not, see <http://www.gnu.org/licenses/>.
+
+package enodes
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// fakeClock is a Clock whose Now() is driven explicitly by tests, letting
+// expiration be exercised deterministically instead of racing a real ticker.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestDB(t *testing.T, opts Options) (*SignedAnnounceVersionDB, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.Unix(1000000, 0)}
+	opts.Clock = clock
+	svdb, err := NewWithBackend(newMemoryStore(), opts)
+	if err != nil {
+		t.Fatalf("NewWithBackend() error = %v", err)
+	}
+	t.Cleanup(func() { svdb.Close() })
+	return svdb, clock
+}
+
+// newSignedENRRecord builds a v4 self-signed ENR at the given sequence
+// number, as a validator's node would before gossiping it.
+func newSignedENRRecord(t *testing.T, nodeKey *ecdsa.PrivateKey, seq uint64) *enr.Record {
+	t.Helper()
+	var r enr.Record
+	r.SetSeq(seq)
+	if err := enode.SignV4(&r, nodeKey); err != nil {
+		t.Fatalf("enode.SignV4() error = %v", err)
+	}
+	return &r
+}
+
+// mustSignAnnounceRecord produces a SignedAnnounceRecord whose Signature is a
+// genuine Istanbul signature over (Address, Seq, Record), as
+// ValidateSignature expects.
+func mustSignAnnounceRecord(t *testing.T, validatorKey *ecdsa.PrivateKey, seq uint64, record *enr.Record) *SignedAnnounceRecord {
+	t.Helper()
+	address := crypto.PubkeyToAddress(validatorKey.PublicKey)
+	payload, err := rlp.EncodeToBytes([]interface{}{address, seq, record})
+	if err != nil {
+		t.Fatalf("rlp.EncodeToBytes() error = %v", err)
+	}
+	sig, err := crypto.Sign(crypto.Keccak256(payload), validatorKey)
+	if err != nil {
+		t.Fatalf("crypto.Sign() error = %v", err)
+	}
+	return &SignedAnnounceRecord{Address: address, Seq: seq, Record: record, Signature: sig}
+}
+
+// newTestSignedAnnounceRecord builds a fully valid, independently keyed
+// SignedAnnounceRecord at the given sequence number, suitable for fixtures
+// that need a record surviving an RLP round trip through the enr codec.
+func newTestSignedAnnounceRecord(t *testing.T, seq uint64) *SignedAnnounceRecord {
+	t.Helper()
+	validatorKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("crypto.GenerateKey() error = %v", err)
+	}
+	nodeKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("crypto.GenerateKey() error = %v", err)
+	}
+	record := newSignedENRRecord(t, nodeKey, seq)
+	return mustSignAnnounceRecord(t, validatorKey, seq, record)
+}
+
+func TestExpireEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		age     time.Duration
+		expired bool
+	}{
+		{name: "entry younger than expiration survives", age: time.Minute, expired: false},
+		{name: "entry exactly at expiration survives", age: time.Hour, expired: false},
+		{name: "entry older than expiration is pruned", age: 2 * time.Hour, expired: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svdb, clock := newTestDB(t, Options{Expiration: time.Hour})
+
+			record := newTestSignedAnnounceRecord(t, 1)
+			if _, err := svdb.Upsert([]*SignedAnnounceRecord{record}); err != nil {
+				t.Fatalf("Upsert() error = %v", err)
+			}
+
+			clock.Advance(tt.age)
+			if err := svdb.expireEntries(); err != nil {
+				t.Fatalf("expireEntries() error = %v", err)
+			}
+
+			_, err := svdb.getEntry(record.Address)
+			if tt.expired && err != ErrNotFound {
+				t.Errorf("getEntry() error = %v, want ErrNotFound", err)
+			}
+			if !tt.expired && err != nil {
+				t.Errorf("getEntry() error = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestUpsertUsesClockForTimestamp(t *testing.T) {
+	svdb, clock := newTestDB(t, Options{})
+
+	record := newTestSignedAnnounceRecord(t, 1)
+	if _, err := svdb.Upsert([]*SignedAnnounceRecord{record}); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+
+	entry, err := svdb.getEntry(record.Address)
+	if err != nil {
+		t.Fatalf("getEntry() error = %v", err)
+	}
+	if !entry.Timestamp.Equal(clock.now) {
+		t.Errorf("entry.Timestamp = %v, want %v (the fake clock's time, not wall-clock time)", entry.Timestamp, clock.now)
+	}
+}
+
+func TestExpireEntriesEmitsRemovedEvent(t *testing.T) {
+	svdb, clock := newTestDB(t, Options{Expiration: time.Hour})
+
+	ch := make(chan []AnnounceVersionEvent, 1)
+	sub := svdb.SubscribeAnnounceVersionBatch(ch)
+	defer sub.Unsubscribe()
+
+	record := newTestSignedAnnounceRecord(t, 1)
+	if _, err := svdb.Upsert([]*SignedAnnounceRecord{record}); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	<-ch // drain the Upsert's own Added notification
+
+	clock.Advance(2 * time.Hour)
+	if err := svdb.expireEntries(); err != nil {
+		t.Fatalf("expireEntries() error = %v", err)
+	}
+
+	select {
+	case events := <-ch:
+		if len(events) != 1 || events[0].Address != record.Address || events[0].Kind != AnnounceVersionRemoved {
+			t.Errorf("got events = %v, want one AnnounceVersionRemoved event for %v", events, record.Address)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the expiration's removed event")
+	}
+}
+
+func TestUpsertAndGetRecordFromAddress(t *testing.T) {
+	svdb, _ := newTestDB(t, Options{})
+
+	record := newTestSignedAnnounceRecord(t, 5)
+	if _, err := svdb.Upsert([]*SignedAnnounceRecord{record}); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+
+	gotRecord, gotSeq, err := svdb.GetRecordFromAddress(record.Address)
+	if err != nil {
+		t.Fatalf("GetRecordFromAddress() error = %v", err)
+	}
+	if gotSeq != 5 {
+		t.Errorf("GetRecordFromAddress() seq = %d, want 5", gotSeq)
+	}
+	gotBytes, err := rlp.EncodeToBytes(gotRecord)
+	if err != nil {
+		t.Fatalf("rlp.EncodeToBytes(got) error = %v", err)
+	}
+	wantBytes, err := rlp.EncodeToBytes(record.Record)
+	if err != nil {
+		t.Fatalf("rlp.EncodeToBytes(want) error = %v", err)
+	}
+	if !bytes.Equal(gotBytes, wantBytes) {
+		t.Errorf("GetRecordFromAddress() record did not round-trip through the store")
+	}
+}
+
+func TestValidateSignatureAcceptsGenuineRejectsGraftedRecord(t *testing.T) {
+	validatorKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("crypto.GenerateKey() error = %v", err)
+	}
+	nodeKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("crypto.GenerateKey() error = %v", err)
+	}
+	record := newSignedENRRecord(t, nodeKey, 1)
+	signed := mustSignAnnounceRecord(t, validatorKey, 1, record)
+
+	if err := signed.ValidateSignature(); err != nil {
+		t.Fatalf("ValidateSignature() on a genuine record error = %v, want nil", err)
+	}
+
+	attackerKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("crypto.GenerateKey() error = %v", err)
+	}
+	grafted := &SignedAnnounceRecord{
+		Address:   signed.Address,
+		Seq:       signed.Seq,
+		Record:    newSignedENRRecord(t, attackerKey, 1),
+		Signature: signed.Signature,
+	}
+	if err := grafted.ValidateSignature(); err == nil {
+		t.Fatal("ValidateSignature() on a record grafted onto someone else's signature error = nil, want error")
+	}
+}
+
+func TestUpsertSendsOneBatchedEventPerCall(t *testing.T) {
+	svdb, _ := newTestDB(t, Options{})
+
+	ch := make(chan []AnnounceVersionEvent, 1)
+	sub := svdb.SubscribeAnnounceVersionBatch(ch)
+	defer sub.Unsubscribe()
+
+	recordA := newTestSignedAnnounceRecord(t, 1)
+	recordB := newTestSignedAnnounceRecord(t, 1)
+	if _, err := svdb.Upsert([]*SignedAnnounceRecord{recordA, recordB}); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+
+	select {
+	case events := <-ch:
+		if len(events) != 2 {
+			t.Fatalf("got %d events in the batch, want 2 (one Upsert call producing one notification)", len(events))
+		}
+		for _, ev := range events {
+			if ev.Kind != AnnounceVersionAdded {
+				t.Errorf("event for %v Kind = %v, want AnnounceVersionAdded", ev.Address, ev.Kind)
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for batched event notification")
+	}
+
+	select {
+	case events := <-ch:
+		t.Fatalf("got unexpected second notification: %v", events)
+	default:
+	}
+}
+
+func TestSubscribeAnnounceVersionDeliversOneAtATime(t *testing.T) {
+	svdb, _ := newTestDB(t, Options{})
+
+	ch := make(chan AnnounceVersionEvent, 2)
+	sub := svdb.SubscribeAnnounceVersion(ch)
+	defer sub.Unsubscribe()
+
+	recordA := newTestSignedAnnounceRecord(t, 1)
+	recordB := newTestSignedAnnounceRecord(t, 1)
+	if _, err := svdb.Upsert([]*SignedAnnounceRecord{recordA, recordB}); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+
+	seen := make(map[common.Address]bool)
+	for i := 0; i < 2; i++ {
+		select {
+		case ev := <-ch:
+			seen[ev.Address] = true
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for event %d", i)
+		}
+	}
+	if !seen[recordA.Address] || !seen[recordB.Address] {
+		t.Errorf("seen = %v, want events for both %v and %v", seen, recordA.Address, recordB.Address)
+	}
+}